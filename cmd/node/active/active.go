@@ -0,0 +1,35 @@
+// Package active holds the subset of pod's configuration that is only
+// meaningful once the active network and dial/lookup strategy have been
+// decided, as opposed to pod/config.Config which holds the raw settings
+// those decisions are made from.
+package active
+
+import (
+	"net"
+	"time"
+
+	"github.com/p9c/matrjoska/pkg/amt"
+)
+
+// DialFunc matches net.DialTimeout's signature; Dial and Oniondial are
+// both of this type so callers can treat them interchangeably once
+// they've picked the right one for a given address.
+type DialFunc func(network, addr string, timeout time.Duration) (net.Conn, error)
+
+// LookupFunc matches net.LookupIP's signature.
+type LookupFunc func(host string) ([]net.IP, error)
+
+// Config holds the network-active state derived by state.GetNew: which
+// dial/lookup functions to use for clearnet and onion peers, and whether
+// the configuration needs to be flushed back to disk.
+type Config struct {
+	Dial      DialFunc
+	Lookup    LookupFunc
+	Oniondial DialFunc
+	// I2PDial is analogous to Oniondial but for addresses ending in ".i2p",
+	// routed through the local SAM streaming session.
+	I2PDial DialFunc
+
+	ActiveMinRelayTxFee amt.Amount
+	Save                bool
+}
@@ -0,0 +1,62 @@
+// Package memdb implements a pure in-memory database driver for the
+// database package, registered under the "memdb" database type. It keeps no
+// on-disk state whatsoever, making it a cheap stand-in for ffldb when
+// running integration tests and regtest CI where developers would
+// otherwise churn their SSDs re-syncing between test iterations.
+package memdb
+
+import (
+	"fmt"
+
+	"github.com/p9c/matrjoska/pkg/database"
+)
+
+const dbType = "memdb"
+
+// parseArgs parses the arguments passed to Open/Create and ensures there is
+// exactly one, which memdb ignores since it never touches disk.
+func parseArgs(funcName string, args ...interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf(
+			"invalid arguments to %s.%s -- expected database name",
+			dbType, funcName,
+		)
+	}
+	if _, ok := args[0].(string); !ok {
+		return fmt.Errorf(
+			"first argument to %s.%s is invalid -- expected database name",
+			dbType, funcName,
+		)
+	}
+	return nil
+}
+
+// openDBDriver is the callback provided during driver registration that is
+// used to open an existing (or, for memdb, brand new) database for use.
+func openDBDriver(args ...interface{}) (database.DB, error) {
+	if e := parseArgs("Open", args...); e != nil {
+		return nil, e
+	}
+	return newMemDB(), nil
+}
+
+// createDBDriver is the callback provided during driver registration that is
+// used to create a new database. For memdb this is identical to opening one
+// since there is nothing to persist.
+func createDBDriver(args ...interface{}) (database.DB, error) {
+	if e := parseArgs("Create", args...); e != nil {
+		return nil, e
+	}
+	return newMemDB(), nil
+}
+
+func init() {
+	driver := database.Driver{
+		DbType: dbType,
+		Create: createDBDriver,
+		Open:   openDBDriver,
+	}
+	if e := database.RegisterDriver(driver); e != nil {
+		panic(fmt.Sprintf("failed to register %s database driver: %v", dbType, e))
+	}
+}
@@ -0,0 +1,347 @@
+package memdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/p9c/matrjoska/pkg/database"
+)
+
+// memDB is a trivial in-memory implementation of database.DB: a tree of
+// nested buckets plus a flat block store, all guarded by a single mutex.
+// There is no on-disk representation, WAL, or crash recovery -- closing
+// the process discards everything, which is exactly what's wanted for
+// ephemeral/test nodes.
+type memDB struct {
+	mtx    sync.RWMutex
+	root   *memBucket
+	blocks map[string][]byte
+	closed bool
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		root:   newMemBucket(),
+		blocks: map[string][]byte{},
+	}
+}
+
+// Type returns the database driver type name for this database.
+func (m *memDB) Type() string {
+	return dbType
+}
+
+// Begin starts a transaction against the in-memory store. Since everything
+// lives behind a single mutex there is no need for snapshotting: read
+// transactions take the read lock, write transactions take the write lock
+// for their whole lifetime. closed is checked only after the lock is held,
+// so a concurrent Close can't slip in between the check and the lock.
+func (m *memDB) Begin(writable bool) (database.Tx, error) {
+	if writable {
+		m.mtx.Lock()
+	} else {
+		m.mtx.RLock()
+	}
+	if m.closed {
+		if writable {
+			m.mtx.Unlock()
+		} else {
+			m.mtx.RUnlock()
+		}
+		return nil, fmt.Errorf("memdb: database is closed")
+	}
+	return &memTx{db: m, writable: writable}, nil
+}
+
+// View executes fn within the context of a managed read-only transaction.
+func (m *memDB) View(fn func(tx database.Tx) error) error {
+	tx, e := m.Begin(false)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = tx.Rollback() }()
+	return fn(tx)
+}
+
+// Update executes fn within the context of a managed read-write
+// transaction, committing on success and rolling back on error or panic.
+func (m *memDB) Update(fn func(tx database.Tx) error) (e error) {
+	tx, e := m.Begin(true)
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	if e = fn(tx); e != nil {
+		_ = tx.Rollback()
+		return e
+	}
+	return tx.Commit()
+}
+
+// Close discards the in-memory store. There is nothing to flush.
+func (m *memDB) Close() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.closed = true
+	m.root = nil
+	m.blocks = nil
+	return nil
+}
+
+// memTx is the transaction handle returned from memDB.Begin. Writes go
+// straight through to the parent database's live bucket tree, guarded by
+// the lock acquired at Begin time; each write also pushes the closure that
+// undoes it onto undo, so Rollback can actually revert them by running undo
+// in reverse instead of just releasing the lock.
+type memTx struct {
+	db       *memDB
+	writable bool
+	done     bool
+	undo     []func()
+}
+
+func (tx *memTx) Metadata() database.Bucket {
+	return &memBucketHandle{tx: tx, bucket: tx.db.root}
+}
+
+func (tx *memTx) Commit() error {
+	return tx.finish(false)
+}
+
+func (tx *memTx) Rollback() error {
+	return tx.finish(true)
+}
+
+func (tx *memTx) finish(rollback bool) error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+	if rollback {
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}
+	if tx.writable {
+		tx.db.mtx.Unlock()
+	} else {
+		tx.db.mtx.RUnlock()
+	}
+	return nil
+}
+
+// StoreBlock records the raw bytes of a block under its hash. hash is
+// whatever the caller uses to identify the block (a chainhash.Hash's byte
+// representation in the real chain database).
+func (tx *memTx) StoreBlock(hash, rawBlock []byte) error {
+	if !tx.writable {
+		return fmt.Errorf("memdb: StoreBlock requires a writable transaction")
+	}
+	k := string(hash)
+	blocks := tx.db.blocks
+	prev, existed := blocks[k]
+	cp := make([]byte, len(rawBlock))
+	copy(cp, rawBlock)
+	blocks[k] = cp
+	if existed {
+		tx.undo = append(tx.undo, func() { blocks[k] = prev })
+	} else {
+		tx.undo = append(tx.undo, func() { delete(blocks, k) })
+	}
+	return nil
+}
+
+// FetchBlock returns the raw bytes previously stored under hash.
+func (tx *memTx) FetchBlock(hash []byte) ([]byte, error) {
+	b, ok := tx.db.blocks[string(hash)]
+	if !ok {
+		return nil, fmt.Errorf("memdb: no block stored for hash %x", hash)
+	}
+	return b, nil
+}
+
+// HasBlock reports whether a block is stored under hash.
+func (tx *memTx) HasBlock(hash []byte) (bool, error) {
+	_, ok := tx.db.blocks[string(hash)]
+	return ok, nil
+}
+
+// memBucket is a node in the in-memory bucket tree: a flat key/value map
+// plus any nested buckets created under it.
+type memBucket struct {
+	values  map[string][]byte
+	buckets map[string]*memBucket
+}
+
+func newMemBucket() *memBucket {
+	return &memBucket{values: map[string][]byte{}, buckets: map[string]*memBucket{}}
+}
+
+// memBucketHandle is the database.Bucket view handed to callers; it binds
+// a memBucket node to the transaction it was opened under, so writes can
+// be rejected on a read-only transaction.
+type memBucketHandle struct {
+	tx     *memTx
+	bucket *memBucket
+}
+
+func (b *memBucketHandle) Get(key []byte) []byte {
+	return b.bucket.values[string(key)]
+}
+
+func (b *memBucketHandle) Put(key, value []byte) error {
+	if !b.tx.writable {
+		return fmt.Errorf("memdb: Put requires a writable transaction")
+	}
+	k := string(key)
+	bucket := b.bucket
+	prev, existed := bucket.values[k]
+	cp := make([]byte, len(value))
+	copy(cp, value)
+	bucket.values[k] = cp
+	if existed {
+		b.tx.undo = append(b.tx.undo, func() { bucket.values[k] = prev })
+	} else {
+		b.tx.undo = append(b.tx.undo, func() { delete(bucket.values, k) })
+	}
+	return nil
+}
+
+func (b *memBucketHandle) Delete(key []byte) error {
+	if !b.tx.writable {
+		return fmt.Errorf("memdb: Delete requires a writable transaction")
+	}
+	k := string(key)
+	bucket := b.bucket
+	prev, existed := bucket.values[k]
+	if !existed {
+		return nil
+	}
+	delete(bucket.values, k)
+	b.tx.undo = append(b.tx.undo, func() { bucket.values[k] = prev })
+	return nil
+}
+
+func (b *memBucketHandle) Bucket(key []byte) database.Bucket {
+	child, ok := b.bucket.buckets[string(key)]
+	if !ok {
+		return nil
+	}
+	return &memBucketHandle{tx: b.tx, bucket: child}
+}
+
+func (b *memBucketHandle) CreateBucket(key []byte) (database.Bucket, error) {
+	if !b.tx.writable {
+		return nil, fmt.Errorf("memdb: CreateBucket requires a writable transaction")
+	}
+	k := string(key)
+	if _, ok := b.bucket.buckets[k]; ok {
+		return nil, fmt.Errorf("memdb: bucket %q already exists", key)
+	}
+	child := newMemBucket()
+	bucket := b.bucket
+	bucket.buckets[k] = child
+	b.tx.undo = append(b.tx.undo, func() { delete(bucket.buckets, k) })
+	return &memBucketHandle{tx: b.tx, bucket: child}, nil
+}
+
+func (b *memBucketHandle) CreateBucketIfNotExists(key []byte) (database.Bucket, error) {
+	if !b.tx.writable {
+		return nil, fmt.Errorf("memdb: CreateBucketIfNotExists requires a writable transaction")
+	}
+	k := string(key)
+	bucket := b.bucket
+	child, ok := bucket.buckets[k]
+	if !ok {
+		child = newMemBucket()
+		bucket.buckets[k] = child
+		b.tx.undo = append(b.tx.undo, func() { delete(bucket.buckets, k) })
+	}
+	return &memBucketHandle{tx: b.tx, bucket: child}, nil
+}
+
+func (b *memBucketHandle) DeleteBucket(key []byte) error {
+	if !b.tx.writable {
+		return fmt.Errorf("memdb: DeleteBucket requires a writable transaction")
+	}
+	k := string(key)
+	bucket := b.bucket
+	child, ok := bucket.buckets[k]
+	if !ok {
+		return nil
+	}
+	delete(bucket.buckets, k)
+	b.tx.undo = append(b.tx.undo, func() { bucket.buckets[k] = child })
+	return nil
+}
+
+func (b *memBucketHandle) ForEach(fn func(k, v []byte) error) error {
+	keys := make([]string, 0, len(b.bucket.values))
+	for k := range b.bucket.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if e := fn([]byte(k), b.bucket.values[k]); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (b *memBucketHandle) Cursor() database.Cursor {
+	keys := make([]string, 0, len(b.bucket.values))
+	for k := range b.bucket.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &memCursor{bucket: b.bucket, keys: keys, pos: -1}
+}
+
+// memCursor walks a memBucket's keys in sorted order, snapshotted at the
+// time the cursor was opened.
+type memCursor struct {
+	bucket *memBucket
+	keys   []string
+	pos    int
+}
+
+func (c *memCursor) First() bool {
+	if len(c.keys) == 0 {
+		c.pos = 0
+		return false
+	}
+	c.pos = 0
+	return true
+}
+
+func (c *memCursor) Next() bool {
+	c.pos++
+	return c.pos < len(c.keys)
+}
+
+func (c *memCursor) Seek(seek []byte) bool {
+	target := string(seek)
+	c.pos = sort.SearchStrings(c.keys, target)
+	return c.pos < len(c.keys)
+}
+
+func (c *memCursor) Key() []byte {
+	if c.pos < 0 || c.pos >= len(c.keys) {
+		return nil
+	}
+	return []byte(c.keys[c.pos])
+}
+
+func (c *memCursor) Value() []byte {
+	if c.pos < 0 || c.pos >= len(c.keys) {
+		return nil
+	}
+	return c.bucket.values[c.keys[c.pos]]
+}
@@ -0,0 +1,159 @@
+// Package netaddr implements a typed, versioned address codec used by the
+// peer/addrmanager persistence layer. Unlike a raw TCP endpoint, peers may
+// be reachable over IPv4, IPv6, Tor v2/v3 onion services, or I2P, so every
+// encoded address carries a one-byte type tag identifying which compact
+// payload follows it.
+package netaddr
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func base32Encode(b []byte) string {
+	return base32Encoding.EncodeToString(b)
+}
+
+// DecodeBase32Host decodes the base32 portion of a ".onion"/".b32.i2p"
+// hostname (without its suffix) back into the raw bytes Addr.Host expects,
+// e.g. for persisting an onion service's own address via savePeerAddr.
+func DecodeBase32Host(host string) ([]byte, error) {
+	return base32Encoding.DecodeString(strings.ToUpper(host))
+}
+
+// addressType identifies the shape of the payload that follows the type
+// tag in an encoded address.
+type addressType byte
+
+const (
+	ipv4 addressType = iota
+	ipv6
+	v2Onion
+	v3Onion
+	i2pDest
+)
+
+// Exported aliases so callers outside this package (peer persistence, the
+// dialer selection in state.GetNew) can construct an Addr without reaching
+// into the unexported constants directly.
+const (
+	IPv4    = ipv4
+	IPv6    = ipv6
+	V2Onion = v2Onion
+	V3Onion = v3Onion
+	I2PDest = i2pDest
+)
+
+const (
+	ipv4Len    = 4
+	ipv6Len    = 16
+	v2OnionLen = 10
+	v3OnionLen = 32
+	i2pDestLen = 32
+)
+
+// CurrentVersion is the on-disk peer database format version produced by
+// this package. Bump it whenever the encoding changes and add an upgrade
+// step in Upgrade.
+const CurrentVersion = 2
+
+// Addr is a node address as stored in the peer/addrmanager database: a
+// typed host (IP, onion, or I2P destination) plus the port it listens on.
+type Addr struct {
+	Type addressType
+	Host []byte
+	Port uint16
+}
+
+// Network implements net.Addr.
+func (a *Addr) Network() string {
+	return "tcp"
+}
+
+// String implements net.Addr, rendering the address the way the dialer
+// selection in state.GetNew expects to see it (IP:port, or
+// base32.onion:port / base32.b32.i2p:port).
+func (a *Addr) String() string {
+	host := a.hostString()
+	return net.JoinHostPort(host, fmt.Sprint(a.Port))
+}
+
+func (a *Addr) hostString() string {
+	switch a.Type {
+	case ipv4, ipv6:
+		return net.IP(a.Host).String()
+	case v2Onion, v3Onion:
+		return strings.ToLower(base32Encode(a.Host)) + ".onion"
+	case i2pDest:
+		return strings.ToLower(base32Encode(a.Host)) + ".b32.i2p"
+	default:
+		return ""
+	}
+}
+
+// Encode writes the type tag followed by the compact payload for addr:
+// 4 or 16 bytes for IPv4/IPv6, 10 bytes for a v2 onion service, 32 bytes
+// for a v3 onion service's ed25519 public key, or 32 bytes for an I2P
+// destination's SHA-256 hash.
+func Encode(addr *Addr) ([]byte, error) {
+	wantLen, e := expectedLen(addr.Type)
+	if e != nil {
+		return nil, e
+	}
+	if len(addr.Host) != wantLen {
+		return nil, fmt.Errorf(
+			"netaddr: host payload for type %d must be %d bytes, got %d",
+			addr.Type, wantLen, len(addr.Host),
+		)
+	}
+	buf := make([]byte, 1+wantLen+2)
+	buf[0] = byte(addr.Type)
+	copy(buf[1:], addr.Host)
+	binary.BigEndian.PutUint16(buf[1+wantLen:], addr.Port)
+	return buf, nil
+}
+
+// Decode reconstructs an Addr (and the net.Addr the dialer selection in
+// state.GetNew dispatches on) from its encoded form.
+func Decode(b []byte) (*Addr, error) {
+	if len(b) < 3 {
+		return nil, fmt.Errorf("netaddr: encoded address too short")
+	}
+	t := addressType(b[0])
+	wantLen, e := expectedLen(t)
+	if e != nil {
+		return nil, e
+	}
+	if len(b) != 1+wantLen+2 {
+		return nil, fmt.Errorf(
+			"netaddr: encoded address has wrong length for type %d: got %d want %d",
+			t, len(b), 1+wantLen+2,
+		)
+	}
+	host := make([]byte, wantLen)
+	copy(host, b[1:1+wantLen])
+	port := binary.BigEndian.Uint16(b[1+wantLen:])
+	return &Addr{Type: t, Host: host, Port: port}, nil
+}
+
+func expectedLen(t addressType) (int, error) {
+	switch t {
+	case ipv4:
+		return ipv4Len, nil
+	case ipv6:
+		return ipv6Len, nil
+	case v2Onion:
+		return v2OnionLen, nil
+	case v3Onion:
+		return v3OnionLen, nil
+	case i2pDest:
+		return i2pDestLen, nil
+	default:
+		return 0, fmt.Errorf("netaddr: unknown address type %d", t)
+	}
+}
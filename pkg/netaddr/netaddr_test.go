@@ -0,0 +1,54 @@
+package netaddr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	cases := []*Addr{
+		{Type: IPv4, Host: []byte{127, 0, 0, 1}, Port: 11047},
+		{Type: IPv6, Host: bytes.Repeat([]byte{0xab}, ipv6Len), Port: 11048},
+		{Type: V2Onion, Host: bytes.Repeat([]byte{0x01}, v2OnionLen), Port: 11047},
+		{Type: V3Onion, Host: bytes.Repeat([]byte{0x02}, v3OnionLen), Port: 11047},
+		{Type: I2PDest, Host: bytes.Repeat([]byte{0x03}, i2pDestLen), Port: 11047},
+	}
+	for _, want := range cases {
+		enc, e := Encode(want)
+		if e != nil {
+			t.Fatalf("Encode(%v) failed: %v", want.Type, e)
+		}
+		got, e := Decode(enc)
+		if e != nil {
+			t.Fatalf("Decode failed for type %v: %v", want.Type, e)
+		}
+		if got.Type != want.Type || got.Port != want.Port || !bytes.Equal(got.Host, want.Host) {
+			t.Fatalf("round trip mismatch for type %v: got %+v, want %+v", want.Type, got, want)
+		}
+	}
+}
+
+func TestEncodeRejectsWrongLength(t *testing.T) {
+	_, e := Encode(&Addr{Type: IPv4, Host: []byte{1, 2, 3}})
+	if e == nil {
+		t.Fatalf("expected Encode to reject a 3-byte IPv4 host")
+	}
+}
+
+func TestUpgradeMigratesLegacyTCPAddresses(t *testing.T) {
+	legacy := map[string][]byte{
+		"peer-v4": {192, 168, 0, 1},
+		"peer-v6": bytes.Repeat([]byte{0xcd}, ipv6Len),
+	}
+	upgraded, e := Upgrade(legacyVersion, legacy)
+	if e != nil {
+		t.Fatalf("Upgrade failed: %v", e)
+	}
+	addr, e := Decode(upgraded["peer-v4"])
+	if e != nil {
+		t.Fatalf("Decode after upgrade failed: %v", e)
+	}
+	if addr.Type != IPv4 || !bytes.Equal(addr.Host, legacy["peer-v4"]) {
+		t.Fatalf("unexpected upgraded address: %+v", addr)
+	}
+}
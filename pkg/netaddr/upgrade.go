@@ -0,0 +1,42 @@
+package netaddr
+
+import "fmt"
+
+// legacyVersion is the peer database format version used before this
+// package existed, when every stored address was an unqualified TCP
+// IPv4/IPv6 endpoint with no type tag.
+const legacyVersion = 1
+
+// Upgrade migrates a peer database from legacyVersion to CurrentVersion by
+// re-encoding each stored address with an explicit type tag, inferred from
+// the length of the legacy host bytes (4 for IPv4, 16 for IPv6). It is a
+// one-shot step: addrmanager should run it once on load and persist
+// CurrentVersion afterwards.
+func Upgrade(version int, legacyAddrs map[string][]byte) (map[string][]byte, error) {
+	if version == CurrentVersion {
+		return legacyAddrs, nil
+	}
+	if version != legacyVersion {
+		return nil, fmt.Errorf("netaddr: cannot upgrade unknown peer database version %d", version)
+	}
+	upgraded := make(map[string][]byte, len(legacyAddrs))
+	for key, raw := range legacyAddrs {
+		var t addressType
+		switch len(raw) {
+		case ipv4Len:
+			t = ipv4
+		case ipv6Len:
+			t = ipv6
+		default:
+			return nil, fmt.Errorf("netaddr: legacy address %q has unexpected length %d", key, len(raw))
+		}
+		host := make([]byte, len(raw))
+		copy(host, raw)
+		enc, e := Encode(&Addr{Type: t, Host: host})
+		if e != nil {
+			return nil, e
+		}
+		upgraded[key] = enc
+	}
+	return upgraded, nil
+}
@@ -0,0 +1,7 @@
+package tlsutil
+
+import "github.com/p9c/log"
+
+var (
+	T, D, I, W, E, F = log.GetLogPrinterSet("pkg/tlsutil")
+)
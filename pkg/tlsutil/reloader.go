@@ -0,0 +1,166 @@
+// Package tlsutil provides TLS certificate hot-reloading for the RPC
+// servers, so operators can rotate RPCCert/RPCKey on disk (or trigger a
+// reload with SIGHUP) without restarting the node. CAFile is read once, when
+// TLSConfigForClientAuth builds the config, and is not reloaded.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/p9c/qu"
+)
+
+// ClientAuth mirrors the RPCClientAuth config option: whether and how
+// strictly the RPC server verifies client certificates for mTLS.
+type ClientAuth string
+
+const (
+	ClientAuthNone             ClientAuth = "none"
+	ClientAuthRequest          ClientAuth = "request"
+	ClientAuthRequireAndVerify ClientAuth = "require-and-verify"
+)
+
+// tlsToken bundles the pieces of state that change together on a reload so
+// GetCertificate/GetClientCertificate always see a consistent pair.
+type tlsToken struct {
+	cert *tls.Certificate
+}
+
+// Reloader keeps the current server certificate behind an atomic.Value so
+// in-flight connections keep using the leaf they negotiated with, while new
+// connections pick up a rotated cert/key pair once the watch loop notices the
+// change (next poll or SIGHUP). The CA pool is not part of the reload.
+type Reloader struct {
+	certPath, keyPath, caPath string
+	current                   atomic.Value // *tlsToken
+	pollInterval              time.Duration
+	quit                      qu.C
+	lastCertMod, lastKeyMod   time.Time
+}
+
+// NewReloader loads the initial cert/key pair from certPath/keyPath and
+// returns a Reloader that watches certPath and keyPath for mtime changes (and
+// reloads immediately on SIGHUP), exiting its watch loop when quit closes.
+// caPath is not watched; it is only read by TLSConfigForClientAuth.
+func NewReloader(certPath, keyPath, caPath string, quit qu.C) (r *Reloader, e error) {
+	r = &Reloader{
+		certPath:     certPath,
+		keyPath:      keyPath,
+		caPath:       caPath,
+		pollInterval: 5 * time.Second,
+		quit:         quit,
+	}
+	if e = r.reload(); E.Chk(e) {
+		return nil, e
+	}
+	go r.watch()
+	return
+}
+
+// GetCertificate is suitable for tls.Config.GetCertificate: it always
+// returns whatever certificate is current at the moment a new connection's
+// handshake begins.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.current.Load().(*tlsToken).cert, nil
+}
+
+// GetClientCertificate is suitable for tls.Config.GetClientCertificate,
+// used when this node is itself a TLS client (e.g. walletrpc dialing
+// chainrpc) and must present its own rotating certificate.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.current.Load().(*tlsToken).cert, nil
+}
+
+// TLSConfigForClientAuth builds a *tls.Config wired to this Reloader's
+// GetCertificate/GetClientCertificate callbacks, with ClientAuth toggling
+// tls.RequireAndVerifyClientCert against the loaded CAFile for mTLS.
+func (r *Reloader) TLSConfigForClientAuth(auth ClientAuth) (cfg *tls.Config, e error) {
+	cfg = &tls.Config{
+		GetCertificate:       r.GetCertificate,
+		GetClientCertificate: r.GetClientCertificate,
+	}
+	switch auth {
+	case ClientAuthNone, "":
+		cfg.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		cfg.ClientAuth = tls.RequestClientCert
+	case ClientAuthRequireAndVerify:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		var caPEM []byte
+		if caPEM, e = ioutil.ReadFile(r.caPath); E.Chk(e) {
+			return nil, e
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("tlsutil: unable to parse CA certificate %s", r.caPath)
+		}
+		cfg.ClientCAs = pool
+	default:
+		return nil, fmt.Errorf("tlsutil: unknown client auth mode %q", auth)
+	}
+	return
+}
+
+// watch polls the watched files for mtime changes and also reloads
+// immediately on SIGHUP, until quit closes.
+func (r *Reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.quit.Wait():
+			return
+		case <-hup:
+			D.Ln("received SIGHUP, reloading TLS certificates")
+			if e := r.reload(); E.Chk(e) {
+			}
+		case <-ticker.C:
+			if r.changed() {
+				D.Ln("TLS certificate files changed on disk, reloading")
+				if e := r.reload(); E.Chk(e) {
+				}
+			}
+		}
+	}
+}
+
+// changed reports whether RPCCert or RPCKey's mtime has moved on since the
+// last successful reload.
+func (r *Reloader) changed() bool {
+	certInfo, e := os.Stat(r.certPath)
+	if e != nil {
+		return false
+	}
+	keyInfo, e := os.Stat(r.keyPath)
+	if e != nil {
+		return false
+	}
+	return certInfo.ModTime().After(r.lastCertMod) || keyInfo.ModTime().After(r.lastKeyMod)
+}
+
+// reload re-reads the cert/key pair from disk and atomically swaps it in.
+func (r *Reloader) reload() (e error) {
+	var cert tls.Certificate
+	if cert, e = tls.LoadX509KeyPair(r.certPath, r.keyPath); E.Chk(e) {
+		return
+	}
+	r.current.Store(&tlsToken{cert: &cert})
+	if certInfo, se := os.Stat(r.certPath); se == nil {
+		r.lastCertMod = certInfo.ModTime()
+	}
+	if keyInfo, se := os.Stat(r.keyPath); se == nil {
+		r.lastKeyMod = keyInfo.ModTime()
+	}
+	return
+}
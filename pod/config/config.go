@@ -0,0 +1,109 @@
+// Package config defines pod's runtime configuration: every setting
+// gathered from the config file, environment and CLI flags, normalized
+// behind a small set of Opt types (Bool/String/Int/StringSlice) so
+// state.GetNew can read, validate and mutate them uniformly.
+package config
+
+// RunningCommand identifies which subcommand pod was invoked with and how
+// to run it. Entrypoint takes an interface{} rather than *state.State to
+// avoid an import cycle between pod/config and pod/state.
+type RunningCommand struct {
+	Name       string
+	Entrypoint func(ifc interface{}) (e error)
+}
+
+// Config holds every configuration value used by state.GetNew.
+type Config struct {
+	Network  String
+	LAN      Bool
+	Solo     Bool
+	PipeLog  Bool
+	DataDir  String
+	LogDir   String
+	LogLevel String
+
+	ClientTLS     Bool
+	ServerTLS     Bool
+	RPCCert       String
+	RPCKey        String
+	CAFile        String
+	OneTimeTLSKey Bool
+	RPCClientAuth String
+
+	Profile              String
+	AddPeers             StringSlice
+	ConnectPeers         StringSlice
+	ProxyAddress         String
+	ProxyUser            String
+	ProxyPass            String
+	OnionEnabled         Bool
+	OnionProxyAddress    String
+	OnionProxyUser       String
+	OnionProxyPass       String
+	TorIsolation         Bool
+	P2PListeners         StringSlice
+	P2PConnect           StringSlice
+	DisableListen        Bool
+	RelayNonStd          Bool
+	RejectNonStd         Bool
+	Username             String
+	LimitUser            String
+	Password             String
+	LimitPass            String
+	UserAgentComments    StringSlice
+	MinRelayTxFee        String
+	AutoListen           Bool
+	AutoPorts            Bool
+	RPCListeners         StringSlice
+	WalletRPCListeners   StringSlice
+	DisableDNSSeed       Bool
+	DisableRPC           Bool
+	RPCMaxConcurrentReqs Int
+	ConfigFile           String
+	DbType               String
+
+	// EmbeddedTor et al. configure state.GetNew's managed tor process,
+	// used in place of an external SOCKS proxy at ProxyAddress.
+	EmbeddedTor    Bool
+	TorDataDir     String
+	TorExecutable  String
+	TorControlPort Int
+
+	// I2PEnabled et al. configure the SAM streaming session opened
+	// alongside the onion/proxy setup above.
+	I2PEnabled    Bool
+	I2PSAMAddress String
+	I2PKeyFile    String
+
+	// PluggableTransport et al. configure the PT subprocess wrapping
+	// StateCfg.Dial for censorship-resistant bootstrap.
+	PluggableTransport String
+	PTExecutable       String
+	PTBridgeLine       String
+	PTStateDir         String
+
+	RunningCommand RunningCommand
+	ShowAll        bool
+	Map            map[string]interface{}
+}
+
+// Initialize makes sure Map is non-nil and hands the configuration to hf for
+// any caller-specific post-processing; it does no sanitizing of its own.
+func (c *Config) Initialize(hf func(ifc interface{}) error) (e error) {
+	if c.Map == nil {
+		c.Map = map[string]interface{}{}
+	}
+	return hf(c)
+}
+
+// WriteToFile writes nothing in this package: it ignores path and always
+// returns nil.
+func (c *Config) WriteToFile(path string) (e error) {
+	return nil
+}
+
+// MarshalJSON always returns an empty JSON object ("{}"); no field is
+// rendered, whether or not ShowAll is set.
+func (c *Config) MarshalJSON() (j []byte, e error) {
+	return []byte("{}"), nil
+}
@@ -0,0 +1,42 @@
+package config
+
+// Bool is a settable boolean option. Unlike the other Opt types in this
+// package it has no V()/Set(): it is read with True()/False() and set with
+// T()/F().
+type Bool struct {
+	v bool
+}
+
+func (o *Bool) True() bool  { return o.v }
+func (o *Bool) False() bool { return !o.v }
+func (o *Bool) T()          { o.v = true }
+func (o *Bool) F()          { o.v = false }
+
+// String is a settable string option.
+type String struct {
+	v string
+}
+
+func (o *String) V() string          { return o.v }
+func (o *String) Set(v string) error { o.v = v; return nil }
+func (o *String) Empty() bool        { return o.v == "" }
+
+// Int is a settable integer option.
+type Int struct {
+	v int
+}
+
+func (o *Int) V() int          { return o.v }
+func (o *Int) Set(v int) error { o.v = v; return nil }
+
+// StringSlice is a settable list-of-strings option, used for peer lists and
+// listener addresses.
+type StringSlice struct {
+	v []string
+}
+
+func (o *StringSlice) V() []string          { return o.v }
+func (o *StringSlice) S() []string          { return o.v }
+func (o *StringSlice) Len() int             { return len(o.v) }
+func (o *StringSlice) Set(v []string) error { o.v = v; return nil }
+func (o *StringSlice) Add(v string) error   { o.v = append(o.v, v); return nil }
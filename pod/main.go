@@ -17,6 +17,7 @@ import (
 
 	// This ensures the database drivers get registered
 	_ "github.com/p9c/matrjoska/pkg/database/ffldb"
+	_ "github.com/p9c/matrjoska/pkg/database/memdb"
 
 	// _ "gioui.org/app/permission/bluetooth"
 	// _ "gioui.org/app/permission/camera"
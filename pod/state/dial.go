@@ -0,0 +1,31 @@
+package state
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// DialFunc matches the signature of StateCfg.Dial/Oniondial/I2PDial.
+type DialFunc func(network, addr string, timeout time.Duration) (net.Conn, error)
+
+// DialerForRaw picks the dial function to reach addr, dispatching on its
+// host suffix: ".onion" goes through onionDial, ".i2p" through i2pDial, and
+// everything else through clearDial. chainrpc and connmgr live outside this
+// module and only ever call StateCfg.Dial, so GetNew folds this dispatch
+// directly into StateCfg.Dial itself (see the wiring there) rather than
+// requiring every caller to switch between Dial/Oniondial/I2PDial itself.
+func (s *State) DialerForRaw(addr string, clearDial, onionDial, i2pDial DialFunc) DialFunc {
+	host, _, e := net.SplitHostPort(addr)
+	if e != nil {
+		host = addr
+	}
+	switch {
+	case strings.HasSuffix(host, ".onion"):
+		return onionDial
+	case strings.HasSuffix(host, ".i2p"):
+		return i2pDial
+	default:
+		return clearDial
+	}
+}
@@ -25,7 +25,9 @@ import (
 	"github.com/p9c/matrjoska/pkg/chainrpc"
 	"github.com/p9c/matrjoska/pkg/connmgr"
 	"github.com/p9c/matrjoska/pkg/fork"
+	"github.com/p9c/matrjoska/pkg/netaddr"
 	"github.com/p9c/matrjoska/pkg/pipe"
+	"github.com/p9c/matrjoska/pkg/tlsutil"
 	"github.com/p9c/matrjoska/pkg/util"
 	"github.com/p9c/matrjoska/pkg/util/routeable"
 	"github.com/p9c/matrjoska/pod/config"
@@ -80,6 +82,16 @@ func GetNew(
 			return
 		}
 	}
+	// s.Config.DbType is the driver name (e.g. "ffldb" or "memdb") that whichever RunningCommand opens the
+	// chain database should pass to database.Open/database.Create -- pkg/database itself isn't part of this
+	// module yet, so there is no such call site in this tree for GetNew to thread it into. The one thing
+	// GetNew can and does enforce on its own is refusing the test-only memdb driver on mainnet.
+	T.Ln("checking database type", s.Config.DbType.V())
+	if s.Config.DbType.V() == "memdb" && s.ActiveNet.Name == "mainnet" {
+		if e = fmt.Errorf("memdb is for testing only and cannot be used on mainnet"); F.Chk(e) {
+			return
+		}
+	}
 	// if pipe logging is enabled, start it up
 	if s.Config.PipeLog.True() {
 		D.Ln("starting up pipe logger")
@@ -157,6 +169,22 @@ func GetNew(
 		}
 		D.Ln("done generating TLS certificates")
 	}
+	// Once the cert/key/CA files are known to exist, hand them to a Reloader and build the *tls.Config
+	// RPCClientAuth selects (mTLS against CAFile, or plain server-only TLS), stashing the result on State as
+	// RPCTLSConfig. pkg/chainrpc and pkg/walletrpc aren't part of this module yet; whichever RunningCommand
+	// brings them in should pass s.RPCTLSConfig straight to their listeners rather than building their own.
+	if s.Config.ClientTLS.True() || s.Config.ServerTLS.True() {
+		if s.TLSReloader, e = tlsutil.NewReloader(
+			s.Config.RPCCert.V(), s.Config.RPCKey.V(), s.Config.CAFile.V(), s.KillAll,
+		); E.Chk(e) {
+			return
+		}
+		if s.RPCTLSConfig, e = s.TLSReloader.TLSConfigForClientAuth(
+			tlsutil.ClientAuth(s.Config.RPCClientAuth.V()),
+		); E.Chk(e) {
+			return
+		}
+	}
 
 	// Validate profile port number
 	T.Ln("validating profile port number")
@@ -172,6 +200,18 @@ func GetNew(
 		}
 	}
 
+	T.Ln("restoring persisted peer addresses")
+	var persisted []*netaddr.Addr
+	if persisted, e = loadPeerAddrs(s.Config.DataDir.V()); E.Chk(e) {
+		e = nil
+	} else if len(persisted) > 0 && s.Config.ConnectPeers.Len() == 0 {
+		for _, pa := range persisted {
+			if e = s.Config.AddPeers.Add(pa.String()); E.Chk(e) {
+				return
+			}
+		}
+	}
+
 	T.Ln("checking addpeer and connectpeer lists")
 	if s.Config.AddPeers.Len() > 0 && s.Config.ConnectPeers.Len() > 0 {
 		e = fmt.Errorf("the addpeers and connectpeers options can not be both set")
@@ -424,6 +464,42 @@ func GetNew(
 			return nil, errors.New("tor has been disabled")
 		}
 	}
+	// If embedded tor is enabled we spawn and manage our own tor process instead of relying on an external
+	// SOCKS proxy, and publish an onion service fronting the configured P2P/RPC listeners.
+	T.Ln("checking embedded tor")
+	if s.Config.EmbeddedTor.True() {
+		if _, e = startEmbeddedTor(s); E.Chk(e) {
+			return
+		}
+	}
+	// If I2P is enabled, open a SAM streaming session alongside whatever tor/proxy setup is active above, and
+	// populate StateCfg.I2PDial so .i2p addresses can be dialed the same way .onion ones are.
+	T.Ln("checking i2p")
+	if s.Config.I2PEnabled.True() {
+		if _, e = startI2P(s); E.Chk(e) {
+			return
+		}
+	}
+	// On censored networks a plain SOCKS proxy to tor may itself be blocked, so when a pluggable transport is
+	// configured we launch it and wrap StateCfg.Dial to go through it instead of dialing the bridge directly.
+	T.Ln("checking pluggable transport")
+	if !s.Config.PluggableTransport.Empty() {
+		if _, e = startPluggableTransport(s); E.Chk(e) {
+			return
+		}
+	}
+	// connmgr/chainrpc dial out through StateCfg.Dial for every peer address, onion and i2p included, so fold
+	// the suffix-based selection chainrpc/connmgr would otherwise have to duplicate into Dial itself here.
+	T.Ln("wrapping dial with onion/i2p suffix dispatch")
+	clearDial, onionDial, i2pDial := s.StateCfg.Dial, s.StateCfg.Oniondial, s.StateCfg.I2PDial
+	s.StateCfg.Dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		switch dialer := s.DialerForRaw(addr, clearDial, onionDial, i2pDial); {
+		case dialer != nil:
+			return dialer(network, addr, timeout)
+		default:
+			return nil, fmt.Errorf("no dialer available for %s", addr)
+		}
+	}
 	if s.StateCfg.Save || !apputil.FileExists(s.Config.ConfigFile.V()) {
 		s.StateCfg.Save = false
 		if s.Config.RunningCommand.Name == "kopach" {
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/eyedeekay/sam3"
+
+	"github.com/p9c/matrjoska/pkg/netaddr"
+)
+
+// i2pSession holds the SAM streaming session used to dial and accept
+// connections over the I2P network, analogous to embeddedTor for onion
+// services.
+type i2pSession struct {
+	sam     *sam3.SAM
+	keys    sam3.I2PKeys
+	stream  *sam3.StreamSession
+	b32Addr string
+}
+
+// startI2P connects to the local SAM bridge, creates or loads a persistent
+// destination keypair from I2PKeyFile, and opens a streaming session. The
+// resulting session's Dial is analogous to Oniondial and is used for
+// addresses ending in ".i2p"; the local .b32.i2p address is also appended
+// to P2PListeners so peers can reach the node over I2P.
+func startI2P(s *State) (sess *i2pSession, e error) {
+	D.Ln("starting i2p sam session")
+	var sam *sam3.SAM
+	if sam, e = sam3.NewSAM(s.Config.I2PSAMAddress.V()); E.Chk(e) {
+		return
+	}
+	var keys sam3.I2PKeys
+	if keys, e = loadOrCreateI2PKeys(sam, s.Config.I2PKeyFile.V()); E.Chk(e) {
+		_ = sam.Close()
+		return
+	}
+	var stream *sam3.StreamSession
+	if stream, e = sam.NewStreamSession("pod-i2p", keys, sam3.Options_Default); E.Chk(e) {
+		_ = sam.Close()
+		return
+	}
+	b32 := stream.Addr().Base32()
+	I.Ln("i2p destination", b32)
+	sess = &i2pSession{sam: sam, keys: keys, stream: stream, b32Addr: b32}
+	s.StateCfg.I2PDial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		return stream.DialContext(network, addr)
+	}
+	if e = s.Config.P2PListeners.Add(net.JoinHostPort(b32, s.ActiveNet.DefaultPort)); E.Chk(e) {
+		e = nil
+	}
+	if dest, de := netaddr.DecodeBase32Host(strings.TrimSuffix(b32, ".b32.i2p")); de == nil {
+		addr := &netaddr.Addr{Type: netaddr.I2PDest, Host: dest, Port: uint16(atoiOrZero(s.ActiveNet.DefaultPort))}
+		if se := savePeerAddr(s.Config.DataDir.V(), addr); E.Chk(se) {
+		}
+	}
+	go func() {
+		<-s.KillAll.Wait()
+		D.Ln("shutting down i2p sam session")
+		if e := stream.Close(); E.Chk(e) {
+		}
+		if e := sam.Close(); E.Chk(e) {
+		}
+	}()
+	return
+}
+
+// loadOrCreateI2PKeys loads a persisted destination keypair from keyFile, or
+// asks the SAM bridge to generate a fresh one and persists it when absent.
+func loadOrCreateI2PKeys(sam *sam3.SAM, keyFile string) (keys sam3.I2PKeys, e error) {
+	var b []byte
+	if b, e = ioutil.ReadFile(keyFile); e == nil {
+		return sam3.NewKeysFromString(string(b))
+	}
+	if keys, e = sam.NewKeys(); E.Chk(e) {
+		return
+	}
+	e = ioutil.WriteFile(keyFile, []byte(keys.String()), 0600)
+	return
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/p9c/matrjoska/pkg/netaddr"
+)
+
+// peerAddrFile is the on-disk peer address list state.GetNew persists our
+// own published onion/I2P addresses to and restores them from, so an
+// address learned via embedded tor or I2P on one run is still known (and
+// can be gossiped) on the next, using the typed netaddr codec so v3 onion
+// and I2P destinations round-trip alongside plain IPs.
+const peerAddrFile = "peers.dat"
+
+// savePeerAddr appends addr, hex-encoded, to <DataDir>/peers.dat, tagged
+// with netaddr.CurrentVersion so a future reader knows how to decode it.
+// It's a no-op if addr is already on file, so republishing the same onion/
+// I2P address across restarts doesn't grow the file without bound.
+func savePeerAddr(dataDir string, addr *netaddr.Addr) (e error) {
+	var existing []*netaddr.Addr
+	if existing, e = loadPeerAddrs(dataDir); e != nil {
+		return e
+	}
+	for _, a := range existing {
+		if a.Type == addr.Type && a.Port == addr.Port && bytes.Equal(a.Host, addr.Host) {
+			return nil
+		}
+	}
+	enc, e := netaddr.Encode(addr)
+	if e != nil {
+		return e
+	}
+	var f *os.File
+	if f, e = os.OpenFile(
+		dataDir+string(os.PathSeparator)+peerAddrFile,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600,
+	); e != nil {
+		return e
+	}
+	defer func() { _ = f.Close() }()
+	_, e = fmt.Fprintf(f, "%d %s\n", netaddr.CurrentVersion, hex.EncodeToString(enc))
+	return e
+}
+
+// loadPeerAddrs reads back every address persisted by savePeerAddr,
+// upgrading any entries written by an older (TCP-only) version of this
+// file in memory before decoding them. The file itself is not rewritten, so
+// such entries are upgraded again on every load.
+func loadPeerAddrs(dataDir string) (addrs []*netaddr.Addr, e error) {
+	var f *os.File
+	if f, e = os.Open(dataDir + string(os.PathSeparator) + peerAddrFile); e != nil {
+		if os.IsNotExist(e) {
+			return nil, nil
+		}
+		return nil, e
+	}
+	defer func() { _ = f.Close() }()
+	sc := bufio.NewScanner(f)
+	legacy := map[string][]byte{}
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		version, ve := strconv.Atoi(fields[0])
+		if ve != nil {
+			continue
+		}
+		raw, he := hex.DecodeString(fields[1])
+		if he != nil {
+			continue
+		}
+		if version != netaddr.CurrentVersion {
+			legacy[fields[1]] = raw
+			continue
+		}
+		addr, de := netaddr.Decode(raw)
+		if de != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if e = sc.Err(); e != nil && e != io.EOF {
+		return addrs, e
+	}
+	if len(legacy) > 0 {
+		var upgraded map[string][]byte
+		if upgraded, e = netaddr.Upgrade(1, legacy); E.Chk(e) {
+			return addrs, nil
+		}
+		for _, raw := range upgraded {
+			if addr, de := netaddr.Decode(raw); de == nil {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs, nil
+}
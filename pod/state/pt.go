@@ -0,0 +1,182 @@
+package state
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/go-socks/socks"
+)
+
+// cmethodTimeout bounds how long we wait for the PT subprocess to report it
+// is ready, so a hung or misbehaving binary can't block node boot forever.
+const cmethodTimeout = 30 * time.Second
+
+// socksFieldLimit is the SOCKS5 username/password field's maximum length
+// (a one-byte length prefix caps each field at 255 bytes).
+const socksFieldLimit = 255
+
+// ptClient holds the running pluggable-transport subprocess (e.g. obfs4proxy)
+// and the local SOCKS5 address it exposes for dialing out through a bridge.
+// exited is closed once cmd.Wait() returns, which is also what reaps the
+// subprocess so it doesn't linger as a zombie after it exits or is killed.
+type ptClient struct {
+	cmd       *exec.Cmd
+	socksAddr string
+	exited    chan struct{}
+}
+
+func (pt *ptClient) hasExited() bool {
+	select {
+	case <-pt.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// startPluggableTransport launches the configured PT binary using the Tor
+// goptlib client-side handshake, parses the CMETHOD line it prints on
+// stdout once it's ready, and wraps StateCfg.Dial so outgoing peer
+// connections are routed SOCKS5 -> PT -> bridge -> tor network. If the PT
+// subprocess dies, the wrapped dialer falls back to whatever Dial was
+// already set.
+func startPluggableTransport(s *State) (pt *ptClient, e error) {
+	D.Ln("starting pluggable transport", s.Config.PluggableTransport.V())
+	cmd := exec.Command(s.Config.PTExecutable.V())
+	cmd.Env = append(
+		cmd.Env,
+		"TOR_PT_MANAGED_TRANSPORT_VER=1",
+		"TOR_PT_STATE_LOCATION="+s.Config.PTStateDir.V(),
+		"TOR_PT_CLIENT_TRANSPORTS="+s.Config.PluggableTransport.V(),
+	)
+	var stdout io.ReadCloser
+	if stdout, e = cmd.StdoutPipe(); E.Chk(e) {
+		return
+	}
+	var stderr io.ReadCloser
+	if stderr, e = cmd.StderrPipe(); E.Chk(e) {
+		return
+	}
+	if e = cmd.Start(); E.Chk(e) {
+		return
+	}
+	go logPTStderr(stderr)
+	// cmd.Wait() is what reaps the child once it exits; run it as soon as the process has started so the
+	// subprocess never lingers as a zombie, and use its completion to detect death for the Dial fallback below.
+	exited := make(chan struct{})
+	pt = &ptClient{cmd: cmd, exited: exited}
+	go func() {
+		if we := cmd.Wait(); we != nil {
+			W.Ln("pluggable transport process exited:", we)
+		}
+		close(exited)
+	}()
+	var socksAddr string
+	if socksAddr, e = waitForCMethod(stdout, s.Config.PluggableTransport.V(), cmethodTimeout); E.Chk(e) {
+		if ke := cmd.Process.Kill(); E.Chk(ke) {
+		}
+		return
+	}
+	I.Ln("pluggable transport ready on", socksAddr)
+	pt.socksAddr = socksAddr
+	fallback := s.StateCfg.Dial
+	bridgeLine := s.Config.PTBridgeLine.V()
+	s.StateCfg.Dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		if pt.hasExited() {
+			W.Ln("pluggable transport process has exited, falling back to direct dial")
+			return fallback(network, addr, timeout)
+		}
+		return dialSocks5WithBridgeArgs(pt.socksAddr, addr, bridgeLine, timeout)
+	}
+	go func() {
+		<-s.KillAll.Wait()
+		D.Ln("shutting down pluggable transport")
+		if e := cmd.Process.Kill(); E.Chk(e) {
+		}
+	}()
+	return
+}
+
+// waitForCMethod scans the PT subprocess' stdout for the
+// "CMETHOD <name> socks5 <host:port>" line goptlib-compatible binaries emit
+// once ready, and returns the advertised SOCKS5 address. It gives up after
+// timeout so a hung or misbehaving PT binary can't block node boot forever.
+func waitForCMethod(r io.Reader, transport string, timeout time.Duration) (addr string, e error) {
+	type result struct {
+		addr string
+		e    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			line := sc.Text()
+			fields := strings.Fields(line)
+			if len(fields) == 4 && fields[0] == "CMETHOD" && fields[1] == transport && fields[2] == "socks5" {
+				done <- result{addr: fields[3]}
+				return
+			}
+			if strings.HasPrefix(line, "CMETHODS DONE") {
+				break
+			}
+		}
+		done <- result{e: fmt.Errorf("pluggable transport %s never reported a CMETHOD line", transport)}
+	}()
+	select {
+	case res := <-done:
+		return res.addr, res.e
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timed out waiting %s for pluggable transport %s to report a CMETHOD line", timeout, transport)
+	}
+}
+
+// logPTStderr forwards the PT subprocess' stderr to the existing log
+// package so operators can diagnose bridge connectivity issues.
+func logPTStderr(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		W.Ln("pt:", sc.Text())
+	}
+}
+
+// dialSocks5WithBridgeArgs connects to the local PT SOCKS5 listener,
+// forwarding the bridge's cert and iat-mode arguments via SOCKS5
+// username/password auth as obfs4proxy expects.
+func dialSocks5WithBridgeArgs(socksAddr, addr, bridgeLine string, timeout time.Duration) (net.Conn, error) {
+	user, pass := bridgeArgsToSocksAuth(bridgeLine)
+	proxy := &socks.Proxy{Addr: socksAddr, Username: user, Password: pass}
+	return proxy.DialTimeout("tcp", addr, timeout)
+}
+
+// bridgeArgsToSocksAuth extracts the "cert=...,iat-mode=..." arguments off
+// a bridge line and packs them into the username/password pair obfs4proxy
+// reads its SOCKS5 auth as: the arguments are joined with ";" into a single
+// string, which goes entirely into the username field unless it overflows
+// the SOCKS5 255-byte field limit, in which case it's split at the nearest
+// ";" boundary so no argument is cut in half.
+func bridgeArgsToSocksAuth(bridgeLine string) (user, pass string) {
+	fields := strings.Fields(bridgeLine)
+	var args []string
+	for _, f := range fields {
+		if strings.Contains(f, "=") {
+			args = append(args, f)
+		}
+	}
+	if len(args) == 0 {
+		return
+	}
+	joined := strings.Join(args, ";")
+	if len(joined) <= socksFieldLimit {
+		return joined, ""
+	}
+	cut := strings.LastIndex(joined[:socksFieldLimit+1], ";")
+	if cut < 0 {
+		cut = socksFieldLimit
+	}
+	return joined[:cut], joined[cut+1:]
+}
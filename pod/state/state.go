@@ -0,0 +1,39 @@
+package state
+
+import (
+	"crypto/tls"
+
+	"github.com/p9c/qu"
+	"go.uber.org/atomic"
+
+	"github.com/p9c/matrjoska/cmd/node/active"
+	"github.com/p9c/matrjoska/pkg/chaincfg"
+	"github.com/p9c/matrjoska/pkg/chainrpc"
+	"github.com/p9c/matrjoska/pkg/tlsutil"
+	"github.com/p9c/matrjoska/pod/config"
+)
+
+// State is the fully initialized runtime context returned by GetNew: the
+// sanitized configuration, the active network parameters derived from it,
+// and every piece of long-lived state (dial/lookup functions, shutdown
+// plumbing) that the running command needs.
+type State struct {
+	Config    *config.Config
+	ConfigMap map[string]interface{}
+	StateCfg  *active.Config
+	ActiveNet *chaincfg.Params
+
+	ChainClientReady qu.C
+	KillAll          qu.C
+	NodeChan         chan *chainrpc.Server
+	Syncing          *atomic.Bool
+
+	// TLSReloader hot-swaps the RPC certificate on mtime change or SIGHUP.
+	// It is nil when neither ClientTLS nor ServerTLS is enabled.
+	TLSReloader *tlsutil.Reloader
+	// RPCTLSConfig is built from TLSReloader with RPCClientAuth applied.
+	// pkg/chainrpc/pkg/walletrpc aren't part of this module yet; whatever
+	// RunningCommand constructs their listeners should use this *tls.Config
+	// directly instead of building its own from RPCCert/RPCKey/CAFile.
+	RPCTLSConfig *tls.Config
+}
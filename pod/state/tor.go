@@ -0,0 +1,144 @@
+package state
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/go-socks/socks"
+	"github.com/cretz/bine/tor"
+
+	"github.com/p9c/matrjoska/pkg/netaddr"
+)
+
+// embeddedTor holds the running managed tor process and the onion service
+// fronting it, so it can be torn down cleanly when KillAll fires.
+type embeddedTor struct {
+	t     *tor.Tor
+	onion *tor.OnionService
+}
+
+// startEmbeddedTor launches tor as a child process using bine, waits for
+// bootstrap to complete over the control port, and publishes an ephemeral
+// v3 onion service fronting the configured P2P and RPC listeners. The onion
+// private key is persisted under TorDataDir so the same .onion address is
+// republished across restarts, and shutdown is wired into KillAll so tor
+// exits cleanly.
+func startEmbeddedTor(s *State) (et *embeddedTor, e error) {
+	D.Ln("starting embedded tor")
+	dataDir := s.Config.TorDataDir.V()
+	startConf := &tor.StartConf{
+		DataDir:           dataDir,
+		ExePath:           s.Config.TorExecutable.V(),
+		RetainTempDataDir: true,
+	}
+	if s.Config.TorControlPort.V() != 0 {
+		startConf.ControlPort = s.Config.TorControlPort.V()
+	}
+	var t *tor.Tor
+	if t, e = tor.Start(context.Background(), startConf); E.Chk(e) {
+		return
+	}
+	bootstrapCtx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	if e = t.EnableNetwork(bootstrapCtx, true); E.Chk(e) {
+		if ce := t.Close(); E.Chk(ce) {
+		}
+		return
+	}
+	keyPath := filepath.Join(dataDir, "onion_v3.key")
+	ports := onionPortsFromListeners(s)
+	listenConf := &tor.ListenConf{
+		Version3:    true,
+		Key:         loadOnionKey(keyPath),
+		RemotePorts: ports,
+	}
+	var onion *tor.OnionService
+	if onion, e = t.Listen(bootstrapCtx, listenConf); E.Chk(e) {
+		if ce := t.Close(); E.Chk(ce) {
+		}
+		return
+	}
+	if e = saveOnionKey(keyPath, onion); E.Chk(e) {
+		D.Ln("unable to persist onion key, address will change on restart:", e)
+		e = nil
+	}
+	I.Ln("published onion service", onion.ID+".onion")
+	if pubKey, de := netaddr.DecodeBase32Host(onion.ID); de == nil && len(ports) > 0 {
+		if se := savePeerAddr(s.Config.DataDir.V(), &netaddr.Addr{
+			Type: netaddr.V3Onion, Host: pubKey, Port: uint16(ports[0].VirtualPort),
+		}); E.Chk(se) {
+		}
+	}
+	et = &embeddedTor{t: t, onion: onion}
+	if socksAddr := t.ControlConn.SocksAddress(); socksAddr != "" {
+		// Route every dial through tor's own SOCKS5 listener, actually
+		// CONNECTing to addr rather than just opening a raw TCP connection
+		// to the SOCKS port itself.
+		dialTor := func(network, addr string, timeout time.Duration) (net.Conn, error) {
+			proxy := &socks.Proxy{Addr: socksAddr}
+			return proxy.DialTimeout(network, addr, timeout)
+		}
+		s.StateCfg.Dial = dialTor
+		s.StateCfg.Oniondial = dialTor
+	}
+	go func() {
+		<-s.KillAll.Wait()
+		D.Ln("shutting down embedded tor")
+		if e := onion.Close(); E.Chk(e) {
+		}
+		if e := t.Close(); E.Chk(e) {
+		}
+	}()
+	return
+}
+
+// onionPortsFromListeners maps the configured P2P and RPC listeners onto
+// the remote ports the onion service should front.
+func onionPortsFromListeners(s *State) (ports []tor.OnionPortSpec) {
+	add := func(listeners []string) {
+		for _, l := range listeners {
+			_, port, e := net.SplitHostPort(l)
+			if e != nil {
+				continue
+			}
+			ports = append(ports, tor.OnionPortSpec{TargetAddress: l, VirtualPort: atoiOrZero(port)})
+		}
+	}
+	add(s.Config.P2PListeners.V())
+	add(s.Config.RPCListeners.V())
+	return
+}
+
+func atoiOrZero(s string) (i int) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		i = i*10 + int(c-'0')
+	}
+	return
+}
+
+// loadOnionKey loads a previously persisted v3 onion private key, returning
+// nil when none is found so bine generates a fresh one.
+func loadOnionKey(keyPath string) tor.OnionKey {
+	b, e := ioutil.ReadFile(keyPath)
+	if e != nil {
+		T.Ln("no persisted onion key found, a new address will be generated")
+		return nil
+	}
+	return &tor.Ed25519KeyPair{PrivateKey: b}
+}
+
+// saveOnionKey persists the onion service's private key so the same address
+// is republished on the next start.
+func saveOnionKey(keyPath string, onion *tor.OnionService) (e error) {
+	kp, ok := onion.Key.(*tor.Ed25519KeyPair)
+	if !ok {
+		return
+	}
+	return ioutil.WriteFile(keyPath, kp.PrivateKey, 0600)
+}